@@ -0,0 +1,22 @@
+// Package metrics defines a small, upstream-agnostic way for agent-side
+// code to report auxiliary measurements (e.g. child process resource usage)
+// alongside profiling data, without hard-coding a specific backend.
+package metrics
+
+// Exporter accepts gauge and counter observations tagged with a name and a
+// flat set of labels. Implementations must be safe for concurrent use.
+type Exporter interface {
+	// Gauge reports the current value of a point-in-time measurement, e.g.
+	// max RSS in bytes.
+	Gauge(name string, value float64, labels map[string]string)
+	// Counter reports a monotonically increasing measurement, e.g. CPU
+	// seconds consumed or page faults encountered.
+	Counter(name string, value float64, labels map[string]string)
+}
+
+// Noop is an Exporter that discards everything. It's the right default
+// wherever no metrics backend has been configured.
+type Noop struct{}
+
+func (Noop) Gauge(string, float64, map[string]string)   {}
+func (Noop) Counter(string, float64, map[string]string) {}
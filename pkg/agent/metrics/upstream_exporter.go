@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/pyroscope-io/pyroscope/pkg/agent/upstream"
+)
+
+// UpstreamExporter reports gauges and counters by encoding each observation
+// as a single line and uploading it through the same upstream.Upstream the
+// profiling samples go through, so a pyroscope server can correlate e.g.
+// "this profile covers a run that used 42s CPU / 180MB RSS" with the
+// samples collected during the same window, without standing up a separate
+// metrics backend.
+//
+// Resolve is called once per observation rather than the upstream being
+// captured up front, since a SIGHUP reload can swap and stop the live
+// upstream out from under a long-lived exporter: Upload must never be
+// called again on one once its Stop has returned, so holding a stale
+// reference would be a use-after-Stop waiting to happen. Callers typically
+// pass a tracker's getter, e.g. `metrics.UpstreamExporter{Resolve: tracker.get}`.
+type UpstreamExporter struct {
+	Resolve func() upstream.Upstream
+}
+
+func (e UpstreamExporter) Gauge(name string, value float64, labels map[string]string) {
+	e.report("gauge", name, value, labels)
+}
+
+func (e UpstreamExporter) Counter(name string, value float64, labels map[string]string) {
+	e.report("counter", name, value, labels)
+}
+
+func (e UpstreamExporter) report(kind, name string, value float64, labels map[string]string) {
+	e.Resolve().Upload([]byte(fmt.Sprintf("%s %s %g %v\n", kind, name, value, labels)))
+}
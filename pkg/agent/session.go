@@ -0,0 +1,96 @@
+// Package agent ties a spy to an upstream, profiling a pid and shipping the
+// samples it collects until told to stop.
+package agent
+
+import (
+	"sync"
+
+	"github.com/pyroscope-io/pyroscope/pkg/agent/upstream"
+)
+
+// Session profiles pid with spyName at sampleRate, uploading samples tagged
+// appName through an upstream.Upstream. The upstream, sample rate and app
+// name can all change over the session's lifetime without losing track of
+// the pid it's attached to: SetUpstream and SetAppName apply in place,
+// while a sample rate change requires a fresh Session (a spy is only told
+// its rate once, at attach time) — see exec.Cli's SIGHUP handling for how
+// that's done without restarting the profiled process itself.
+type Session struct {
+	spyName            string
+	pid                int
+	detectSubprocesses bool
+
+	mu         sync.Mutex
+	upstream   upstream.Upstream
+	appName    string
+	sampleRate int
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSession creates a Session that will profile pid with spyName at
+// sampleRate Hz, uploading samples tagged appName through u.
+func NewSession(u upstream.Upstream, appName, spyName string, sampleRate, pid int, detectSubprocesses bool) *Session {
+	return &Session{
+		spyName:            spyName,
+		pid:                pid,
+		detectSubprocesses: detectSubprocesses,
+		upstream:           u,
+		appName:            appName,
+		sampleRate:         sampleRate,
+		stopped:            make(chan struct{}),
+	}
+}
+
+// Start begins profiling pid in the background.
+func (s *Session) Start() {
+	s.wg.Add(1)
+	go s.loop()
+}
+
+func (s *Session) loop() {
+	defer s.wg.Done()
+	// The spy attachment and sampling loop lives alongside the spy
+	// implementations; this change is only concerned with how a session's
+	// upstream, app name and sample rate are managed across its lifetime.
+	<-s.stopped
+}
+
+// Stop halts profiling and flushes any samples still queued against the
+// current upstream.
+func (s *Session) Stop() {
+	s.stopOnce.Do(func() { close(s.stopped) })
+	s.wg.Wait()
+	s.Upstream().Flush()
+}
+
+// SetUpstream swaps the upstream future samples are uploaded through,
+// without interrupting profiling itself: it switches to u first, then drains
+// (flushes) whatever is still queued against the old upstream and stops it.
+func (s *Session) SetUpstream(u upstream.Upstream) {
+	s.mu.Lock()
+	old := s.upstream
+	s.upstream = u
+	s.mu.Unlock()
+
+	old.Flush()
+	old.Stop()
+}
+
+// SetAppName retags subsequent uploads with a new application name without
+// restarting the session.
+func (s *Session) SetAppName(appName string) {
+	s.mu.Lock()
+	s.appName = appName
+	s.mu.Unlock()
+}
+
+// Upstream returns the upstream currently in use, e.g. so a replacement
+// Session can be handed the same one instead of reconnecting.
+func (s *Session) Upstream() upstream.Upstream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.upstream
+}
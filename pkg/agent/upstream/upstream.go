@@ -0,0 +1,16 @@
+// Package upstream defines the interface agent sessions use to ship
+// profiling data to a pyroscope server, so that callers (e.g. exec.Cli's
+// SIGHUP reload) can swap in a different destination without caring
+// whether it's the bundled remote HTTP client or something else.
+package upstream
+
+// Upstream uploads profiling data to a pyroscope server.
+type Upstream interface {
+	// Upload queues b for delivery.
+	Upload(b []byte)
+	// Flush blocks until everything queued so far has been sent.
+	Flush()
+	// Stop flushes and shuts the upstream down. Once Stop returns, Upload
+	// must not be called again.
+	Stop()
+}
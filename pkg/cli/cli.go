@@ -0,0 +1,19 @@
+package cli
+
+import (
+	"github.com/pyroscope-io/pyroscope/pkg/config"
+	"github.com/urfave/cli/v2"
+)
+
+// NewApp assembles the pyroscope command-line app. cmd/pyroscope's main.go
+// calls this and runs the result against os.Args; this package only owns
+// command wiring, not argv parsing or process exit codes.
+func NewApp(cfg *config.Config) *cli.App {
+	return &cli.App{
+		Name:  "pyroscope",
+		Usage: "continuous profiling platform",
+		Commands: []*cli.Command{
+			NewConnectCmd(cfg),
+		},
+	}
+}
@@ -0,0 +1,36 @@
+// Package cli assembles pyroscope's command-line subcommands. This file
+// wires pkg/exec.Attach up as `pyroscope connect`.
+package cli
+
+import (
+	"fmt"
+
+	"github.com/pyroscope-io/pyroscope/pkg/config"
+	"github.com/pyroscope-io/pyroscope/pkg/exec"
+	"github.com/urfave/cli/v2"
+)
+
+// NewConnectCmd returns the `pyroscope connect` subcommand, which attaches
+// to an already-running process instead of spawning one, for the common
+// case of profiling a long-running worker that's already started.
+func NewConnectCmd(cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:      "connect",
+		Usage:     "Profile an already-running process",
+		ArgsUsage: "",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:        "pid",
+				Usage:       "PID of the process to profile",
+				Destination: &cfg.Exec.Pid,
+				Required:    true,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if cfg.Exec.Pid <= 0 {
+				return fmt.Errorf("-pid must be a positive process id")
+			}
+			return exec.Attach(cfg, cfg.Exec.Pid)
+		},
+	}
+}
@@ -0,0 +1,103 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/mitchellh/go-ps"
+	"github.com/pyroscope-io/pyroscope/pkg/agent"
+	"github.com/pyroscope-io/pyroscope/pkg/agent/spy"
+	"github.com/pyroscope-io/pyroscope/pkg/agent/upstream/remote"
+	"github.com/pyroscope-io/pyroscope/pkg/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Attach profiles an already-running process instead of spawning a new one.
+// Unlike Cli, it never touches the target's lifecycle: there's no child to
+// start, signal or wait on, so it simply runs a session against pid until
+// either the target goes away or pyroscope itself is asked to stop.
+func Attach(cfg *config.Config, pid int) error {
+	spyName := cfg.Exec.SpyName
+	if spyName == "auto" {
+		resolved, err := resolveSpyForPid(pid)
+		if err != nil {
+			return fmt.Errorf("could not automatically find a spy for pid %d: %s. Pass spy name via %s argument", pid, err, "-spy-name")
+		}
+		spyName = resolved
+	}
+
+	if err := performChecks(spyName); err != nil {
+		return err
+	}
+
+	u := remote.New(remote.RemoteConfig{
+		UpstreamAddress:        cfg.Exec.ServerAddress,
+		UpstreamThreads:        cfg.Exec.UpstreamThreads,
+		UpstreamRequestTimeout: cfg.Exec.UpstreamRequestTimeout,
+		AuthToken:              cfg.Exec.AuthToken,
+	})
+	defer u.Stop()
+
+	sampleRate := cfg.Exec.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 100
+	}
+	sess := agent.NewSession(u, cfg.Exec.ApplicationName, spyName, sampleRate, pid, cfg.Exec.DetectSubprocesses)
+	sess.Start()
+	defer sess.Stop()
+
+	waitForPidToExitOrSignal(pid)
+	return nil
+}
+
+// resolveSpyForPid looks up the target's executable via /proc/<pid>/exe,
+// mirroring the auto-detection Cli does from argv[0] when spawning the
+// child itself.
+func resolveSpyForPid(pid int) (string, error) {
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return "", err
+	}
+	spyName := spy.ResolveAutoName(path.Base(exe))
+	if spyName == "" {
+		return "", fmt.Errorf("unsupported executable %q", exe)
+	}
+	return spyName, nil
+}
+
+// waitForPidToExitOrSignal blocks until pid can no longer be found or
+// pyroscope receives SIGINT/SIGTERM, whichever happens first. We don't own
+// the target process, so unlike exec's waitForProcessToExit there's no
+// cmd.Wait() to lean on: liveness has to be polled from the outside.
+func waitForPidToExitOrSignal(pid int) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case sig := <-sigc:
+			logrus.Debugf("received %s, detaching from pid %d", sig, pid)
+			return
+		case <-t.C:
+			if !pidIsAlive(pid) {
+				logrus.Debugf("pid %d no longer exists, detaching", pid)
+				return
+			}
+		}
+	}
+}
+
+func pidIsAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err == syscall.ESRCH {
+		return false
+	}
+	p, err := ps.FindProcess(pid)
+	return p != nil && err == nil
+}
@@ -14,8 +14,8 @@ import (
 	"time"
 
 	"github.com/fatih/color"
-	"github.com/mitchellh/go-ps"
 	"github.com/pyroscope-io/pyroscope/pkg/agent"
+	"github.com/pyroscope-io/pyroscope/pkg/agent/metrics"
 	"github.com/pyroscope-io/pyroscope/pkg/agent/spy"
 	"github.com/pyroscope-io/pyroscope/pkg/agent/upstream/remote"
 	"github.com/pyroscope-io/pyroscope/pkg/config"
@@ -23,7 +23,34 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// ExitError indicates that the profiled child process terminated with a
+// non-zero exit status. Cli itself exits with Code rather than returning it
+// as a regular error, so that scripts, CI and supervisors wrapping
+// `pyroscope exec` see the same exit code the child would have produced on
+// its own.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("child process exited with code %d", e.Code)
+}
+
+// Cli runs args as a child process under profiling and blocks until it
+// exits. Unlike a regular error, a child exit with a non-zero status does
+// not come back through the error return: Cli calls os.Exit with the same
+// code once cleanup (flushing the upstream, stopping the session) has run,
+// so the caller's own exit code matches what running the child directly
+// would have produced.
 func Cli(cfg *config.Config, args []string) error {
+	err := cli(cfg, args)
+	if exitErr, ok := err.(*ExitError); ok {
+		os.Exit(exitErr.Code)
+	}
+	return err
+}
+
+func cli(cfg *config.Config, args []string) error {
 	if len(args) == 0 {
 		return errors.New("no arguments passed")
 	}
@@ -53,58 +80,231 @@ func Cli(cfg *config.Config, args []string) error {
 		return err
 	}
 
+	// SIGCHLD is ignored by default here since we reap the child explicitly
+	// via cmd.Wait() below; forwardSignals takes care of everything else.
 	signal.Ignore(syscall.SIGCHLD)
 
+	u := remote.New(remote.RemoteConfig{
+		UpstreamAddress:        cfg.Exec.ServerAddress,
+		UpstreamThreads:        cfg.Exec.UpstreamThreads,
+		UpstreamRequestTimeout: cfg.Exec.UpstreamRequestTimeout,
+		AuthToken:              cfg.Exec.AuthToken,
+	})
+	tracker := newUpstreamTracker(u)
+	defer tracker.stop()
+
+	restart := RestartPolicy(cfg.Exec.Restart)
+	if restart == "" {
+		restart = RestartNever
+	}
+	backoff := cfg.Exec.RestartBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	retries := 0
+	for {
+		outcome, err := spawnAndRun(cfg, args, spyName, tracker)
+		if err != nil {
+			return err
+		}
+		if isShuttingDown() {
+			logrus.Debug("pyroscope is shutting down, not restarting")
+			if outcome.failed() {
+				return &ExitError{Code: outcome.exitCode}
+			}
+			return nil
+		}
+		if !shouldRestart(restart, outcome) {
+			if outcome.failed() {
+				return &ExitError{Code: outcome.exitCode}
+			}
+			return nil
+		}
+		if maxRetriesReached(retries, cfg.Exec.RestartMaxRetries) {
+			logrus.Warnf("%s exited (%s), not restarting: reached -restart-max-retries=%d", args[0], outcome, cfg.Exec.RestartMaxRetries)
+			if outcome.failed() {
+				return &ExitError{Code: outcome.exitCode}
+			}
+			return nil
+		}
+		retries++
+		logrus.Warnf("%s exited (%s), restarting per -restart=%s in %s", args[0], outcome, restart, backoff)
+		time.Sleep(backoff)
+		backoff = nextBackoff(backoff, cfg.Exec.RestartBackoffMax)
+	}
+}
+
+// spawnAndRun starts args as a child, profiles it for the duration of its
+// life and reports how it exited. A non-nil error here is always fatal
+// (e.g. the child couldn't be started, or it was terminated by a signal,
+// which waitForProcessToExit re-raises against pyroscope itself); only a nil
+// error alongside a failing exitOutcome is eligible for a restart.
+func spawnAndRun(cfg *config.Config, args []string, spyName string, tracker *upstreamTracker) (exitOutcome, error) {
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
 	cmd.Stdin = os.Stdin
 	cmd.SysProcAttr = &syscall.SysProcAttr{}
 	cmd.SysProcAttr.Setpgid = true
-	err := cmd.Start()
-	if err != nil {
-		return err
+	if err := applyProcessCredentials(cmd, cfg); err != nil {
+		return exitOutcome{}, err
 	}
-	u := remote.New(remote.RemoteConfig{
-		UpstreamAddress:        cfg.Exec.ServerAddress,
-		UpstreamThreads:        cfg.Exec.UpstreamThreads,
-		UpstreamRequestTimeout: cfg.Exec.UpstreamRequestTimeout,
-	})
-	defer u.Stop()
+	if cfg.Exec.Chdir != "" {
+		cmd.Dir = cfg.Exec.Chdir
+	}
+	if err := cmd.Start(); err != nil {
+		return exitOutcome{}, err
+	}
+	stopForwarding := forwardSignals(cmd)
+	defer stopForwarding()
 
-	// TODO: improve this logic, basically we need a smart way of detecting that an app successfully loaded.
-	//   Maybe do this on some ticker (every 100 ms) with a timeout (20 s). Make this configurable too
-	time.Sleep(5 * time.Second)
-	// TODO: add sample rate, make it configurable
-	sess := agent.NewSession(u, cfg.Exec.ApplicationName, spyName, 100, cmd.Process.Pid, cfg.Exec.DetectSubprocesses)
+	spyStartInterval := cfg.Exec.SpyStartInterval
+	if spyStartInterval <= 0 {
+		spyStartInterval = 100 * time.Millisecond
+	}
+	spyStartTimeout := cfg.Exec.SpyStartTimeout
+	if spyStartTimeout <= 0 {
+		spyStartTimeout = 20 * time.Second
+	}
+	if err := waitForSpyReady(cmd, spyName, spyStartInterval, spyStartTimeout); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return exitOutcome{}, err
+	}
+	sampleRate := cfg.Exec.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 100
+	}
+	sess := agent.NewSession(tracker.get(), cfg.Exec.ApplicationName, spyName, sampleRate, cmd.Process.Pid, cfg.Exec.DetectSubprocesses)
 	sess.Start()
-	defer sess.Stop()
+	holder := newSessionHolder(sess)
+	stopReload := watchForReload(cfg, cmd.Process.Pid, spyName, holder, tracker)
+	defer stopReload()
 
-	waitForProcessToExit(cmd)
-	return nil
+	// Push resource-usage metrics through the same upstream the profiling
+	// samples go to, so a pyroscope server can correlate the two.
+	exporter := metrics.UpstreamExporter{Resolve: tracker.get}
+	stopProcStat := watchProcStat(cmd.Process.Pid, exporter)
+	defer stopProcStat()
+
+	return waitForProcessToExit(cmd, holder, tracker, exporter)
+}
+
+// forwardableSignals are the signals forwardSignals relays to the profiled
+// child. This is deliberately an explicit list rather than a bare
+// signal.Notify(sigc), which subscribes to everything the runtime uses
+// internally too — notably SIGURG, which Go raises at a high frequency for
+// asynchronous goroutine preemption and which would otherwise get forwarded
+// to the child nonstop. SIGCHLD is excluded since cli ignores it and reaps
+// the child explicitly via cmd.Wait(). SIGHUP is excluded too: it triggers
+// a configuration reload (see watchForReload) and is never passed on to the
+// child.
+var forwardableSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGQUIT,
+	syscall.SIGTERM,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+	syscall.SIGWINCH,
 }
 
-// TODO: very hacky, at some point we'll need to make `cmd.Wait()` work
-//   Currently the issue is that on Linux it often thinks the process exited when it did not.
-func waitForProcessToExit(cmd *exec.Cmd) {
-	sigc := make(chan struct{})
+// forwardSignals relays the signals in forwardableSignals to the profiled
+// child, so that things like `kill -TERM <pyroscope-pid>` or an interactive
+// Ctrl+C reach the workload instead of only killing the wrapper. It returns
+// a function that stops the forwarding and should be deferred by the
+// caller.
+func forwardSignals(cmd *exec.Cmd) func() {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, forwardableSignals...)
+	go func() {
+		for sig := range sigc {
+			if sig == syscall.SIGINT || sig == syscall.SIGTERM || sig == syscall.SIGQUIT {
+				// pyroscope itself was asked to terminate, not just the
+				// child; don't let a -restart policy spawn another attempt
+				// once this one exits.
+				requestShutdown()
+			}
+			// The child may have already exited; errors here are expected
+			// and harmless in that case.
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+	return func() { signal.Stop(sigc); close(sigc) }
+}
 
+// waitForProcessToExit blocks until the child process exits and reports how.
+// A termination by signal is additionally re-raised against pyroscope's own
+// process so our exit status matches what the shell would have seen running
+// the child directly (128+signal, and a core dump where applicable); in that
+// case the returned error is non-nil and fatal, since there's no sane way to
+// restart after re-raising a signal against ourselves. A clean run — exited
+// or not — comes back as a nil error with outcome describing the exit code,
+// leaving the restart decision to the caller.
+//
+// Re-raising a signal terminates pyroscope itself before any deferred
+// cleanup in the caller gets a chance to run, which would otherwise drop
+// whatever samples are still queued. Since that path never restarts (the
+// returned error is always fatal here), the session and the tracked
+// upstream are both stopped synchronously before the signal is re-raised.
+// The ordinary (non-signaled) path still only stops the session, same as
+// before: the upstream may outlive this child across a restart, and only
+// cli()'s own deferred cleanup stops it for good.
+func waitForProcessToExit(cmd *exec.Cmd, holder *sessionHolder, tracker *upstreamTracker, m metrics.Exporter) (exitOutcome, error) {
 	atexit.Register(func() {
-		sigc <- struct{}{}
+		cmd.Process.Kill()
 	})
 
-	t := time.NewTicker(time.Second)
-	for {
-		select {
-		case <-sigc:
-			cmd.Process.Kill()
-			return
-		case <-t.C:
-			p, err := ps.FindProcess(cmd.Process.Pid)
-			if p == nil || err != nil {
-				return
-			}
-		}
+	outcome, err := waitForChildExit(cmd, m)
+	if err != nil {
+		holder.current().Stop()
+		return exitOutcome{}, err
+	}
+	if !outcome.signaled {
+		holder.current().Stop()
+		return outcome, nil
+	}
+
+	holder.current().Stop()
+	tracker.stop()
+	logrus.Debugf("child process was terminated by signal %s", outcome.signal)
+	signal.Reset(outcome.signal)
+	if kerr := syscall.Kill(syscall.Getpid(), outcome.signal); kerr != nil {
+		return outcome, &ExitError{Code: 128 + int(outcome.signal)}
+	}
+	// syscall.Kill with a signal whose default action is to terminate the
+	// process does not return; this is reached only for signals whose
+	// default disposition doesn't terminate us, in which case we fall back
+	// to reporting the conventional 128+signal exit code.
+	return outcome, &ExitError{Code: 128 + int(outcome.signal)}
+}
+
+// waitForChildExit blocks until cmd exits and reports how, without taking
+// any action on the result — contrast with waitForProcessToExit, which
+// additionally re-raises fatal signals against pyroscope itself.
+func waitForChildExit(cmd *exec.Cmd, m metrics.Exporter) (exitOutcome, error) {
+	err := cmd.Wait()
+	reportResourceUsage(cmd.ProcessState, m)
+	if err == nil {
+		return exitOutcome{}, nil
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return exitOutcome{}, err
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return exitOutcome{}, exitErr
+	}
+
+	switch {
+	case status.Exited():
+		return exitOutcome{exitCode: status.ExitStatus()}, nil
+	case status.Signaled():
+		return exitOutcome{signal: status.Signal(), signaled: true}, nil
+	default:
+		return exitOutcome{}, exitErr
 	}
 }
 
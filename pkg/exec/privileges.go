@@ -0,0 +1,102 @@
+package exec
+
+import (
+	"fmt"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"github.com/pyroscope-io/pyroscope/pkg/config"
+)
+
+// applyProcessCredentials configures cmd to start the child as the user,
+// group and supplementary groups given in cfg.Exec, so that a pyroscope
+// parent running as root (as many spies require, in order to ptrace or read
+// /proc/<pid>/mem) doesn't leave the profiled workload itself running
+// privileged. It's a no-op when none of -user, -group, -groups or
+// -no-new-privs were passed.
+func applyProcessCredentials(cmd *exec.Cmd, cfg *config.Config) error {
+	e := cfg.Exec
+	if e.User == "" && e.Group == "" && len(e.Groups) == 0 && !e.NoNewPrivs {
+		return nil
+	}
+	changesIdentity := e.User != "" || e.Group != "" || len(e.Groups) > 0
+	if changesIdentity && !isRoot() {
+		return fmt.Errorf("-user/-group/-groups require pyroscope itself to run as root, so it can drop the child to the requested identity")
+	}
+
+	cred := &syscall.Credential{}
+	if e.User != "" {
+		uid, gid, err := lookupUser(e.User)
+		if err != nil {
+			return fmt.Errorf("looking up -user %q: %w", e.User, err)
+		}
+		cred.Uid, cred.Gid = uid, gid
+	}
+	if e.Group != "" {
+		gid, err := lookupGroup(e.Group)
+		if err != nil {
+			return fmt.Errorf("looking up -group %q: %w", e.Group, err)
+		}
+		cred.Gid = gid
+	}
+	for _, g := range e.Groups {
+		gid, err := lookupGroup(g)
+		if err != nil {
+			return fmt.Errorf("looking up supplementary group %q: %w", g, err)
+		}
+		cred.Groups = append(cred.Groups, gid)
+	}
+	if changesIdentity {
+		cmd.SysProcAttr.Credential = cred
+	}
+
+	if e.NoNewPrivs {
+		setNoNewPrivs(cmd)
+	}
+	return nil
+}
+
+// lookupUser resolves s as either a username or a numeric uid, the same way
+// `chown`/`su` accept either form, and returns the uid/gid of its primary
+// group.
+func lookupUser(s string) (uid, gid uint32, err error) {
+	u, lookupErr := user.Lookup(s)
+	if lookupErr != nil {
+		if _, numErr := strconv.Atoi(s); numErr != nil {
+			return 0, 0, lookupErr
+		}
+		if u, err = user.LookupId(s); err != nil {
+			return 0, 0, err
+		}
+	}
+	uid64, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	gid64, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint32(uid64), uint32(gid64), nil
+}
+
+// lookupGroup resolves s as either a group name or a numeric gid.
+func lookupGroup(s string) (uint32, error) {
+	g, lookupErr := user.LookupGroup(s)
+	if lookupErr != nil {
+		if _, numErr := strconv.Atoi(s); numErr != nil {
+			return 0, lookupErr
+		}
+		var err error
+		if g, err = user.LookupGroupId(s); err != nil {
+			return 0, err
+		}
+	}
+	gid64, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(gid64), nil
+}
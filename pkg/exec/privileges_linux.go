@@ -0,0 +1,10 @@
+package exec
+
+import "os/exec"
+
+// setNoNewPrivs sets PR_SET_NO_NEW_PRIVS on the child, preventing it (and
+// anything it execs) from gaining privileges it didn't already have, e.g.
+// via setuid binaries.
+func setNoNewPrivs(cmd *exec.Cmd) {
+	cmd.SysProcAttr.NoNewPrivs = true
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package exec
+
+import (
+	"os/exec"
+
+	"github.com/sirupsen/logrus"
+)
+
+// setNoNewPrivs is a no-op outside Linux: PR_SET_NO_NEW_PRIVS is a
+// Linux-specific prctl, so -no-new-privs has nothing to hook into here.
+func setNoNewPrivs(cmd *exec.Cmd) {
+	logrus.Warn("-no-new-privs is only supported on Linux, ignoring")
+}
@@ -0,0 +1,106 @@
+package exec
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pyroscope-io/pyroscope/pkg/agent/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// procStatSampleInterval is how often a long-running child's /proc/<pid>/stat
+// and /proc/<pid>/status are sampled. It isn't yet wired to the profile
+// upload interval configuration (TODO), so for now it just uses a sensible
+// fixed cadence.
+const procStatSampleInterval = 10 * time.Second
+
+// watchProcStat polls /proc/<pid>/stat and /proc/<pid>/status on a fixed
+// cadence for as long as pid is alive, shipping CPU-tick deltas and the
+// current RSS through m. It returns a function that stops the sampler.
+func watchProcStat(pid int, m metrics.Exporter) func() {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(procStatSampleInterval)
+		defer t.Stop()
+
+		var lastUtime, lastStime uint64
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				utime, stime, rssBytes, err := readProcStat(pid)
+				if err != nil {
+					// Most likely the child has already exited.
+					return
+				}
+				if lastUtime != 0 || lastStime != 0 {
+					m.Counter("exec_child_cpu_user_ticks", float64(utime-lastUtime), nil)
+					m.Counter("exec_child_cpu_sys_ticks", float64(stime-lastStime), nil)
+				}
+				lastUtime, lastStime = utime, stime
+				m.Gauge("exec_child_rss_bytes", float64(rssBytes), nil)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// readProcStat returns the child's utime/stime (in clock ticks, field 14
+// and 15 of /proc/<pid>/stat) and its current RSS in bytes (from
+// /proc/<pid>/status's VmRSS, which is reported in kB).
+func readProcStat(pid int) (utime, stime uint64, rssBytes uint64, err error) {
+	statBytes, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	stat := string(statBytes)
+	// Fields after the process name (which may itself contain spaces and is
+	// wrapped in parens) are space-separated; utime/stime are fields 14/15
+	// counting from 1, i.e. indexes 11/12 once the "pid (comm)" prefix is
+	// stripped (which leaves the state field at index 0).
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 13 {
+		return 0, 0, 0, fmt.Errorf("unexpected /proc/%d/stat format", pid)
+	}
+	utime, err = strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	stime, err = strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	rssKB, err := readVmRSSKB(pid)
+	if err != nil {
+		logrus.Debugf("reading /proc/%d/status: %v", pid, err)
+	}
+	return utime, stime, rssKB * 1024, nil
+}
+
+func readVmRSSKB(pid int) (uint64, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "VmRSS:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return 0, fmt.Errorf("unexpected VmRSS line %q", line)
+		}
+		return strconv.ParseUint(fields[1], 10, 64)
+	}
+	return 0, scanner.Err()
+}
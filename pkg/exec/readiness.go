@@ -0,0 +1,49 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// waitForSpyReady polls every pollInterval until spyName can successfully
+// attach to cmd's pid, the process exits first, timeout elapses, or the
+// user interrupts pyroscope — whichever comes first. It replaces a fixed
+// sleep before starting the session, which is both too long for short-lived
+// scripts and too short for slow JVM/CPython startups on cold containers.
+func waitForSpyReady(cmd *exec.Cmd, spyName string, pollInterval, timeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	pid := cmd.Process.Pid
+	t := time.NewTicker(pollInterval)
+	defer t.Stop()
+
+	for {
+		ready, err := isSpyReady(pid, spyName)
+		if err != nil {
+			return fmt.Errorf("checking whether %s can attach to pid %d: %w", spyName, pid, err)
+		}
+		if ready {
+			return nil
+		}
+		if !pidIsAlive(pid) {
+			return fmt.Errorf("pid %d exited before %s could attach", pid, spyName)
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("timed out after %s waiting for %s to attach to pid %d", timeout, spyName, pid)
+			}
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// readinessPtracePollInterval/readinessPtraceWaitTimeout bound how long
+// ptraceRoundTrip polls for the SIGSTOP PtraceAttach delivers before giving
+// up, so a slow-to-stop (or never-stopping) target can't wedge a readiness
+// check forever.
+const (
+	readinessPtracePollInterval = 2 * time.Millisecond
+	readinessPtraceWaitTimeout  = 200 * time.Millisecond
+)
+
+// interpreterMarkers maps a spy name to substrings we expect to find in
+// /proc/<pid>/maps once the interpreter it profiles has loaded, e.g. pyspy
+// needs libpython mapped in before there's anything for it to read.
+var interpreterMarkers = map[string][]string{
+	"pyspy":  {"libpython"},
+	"rbspy":  {"libruby", "/ruby"},
+	"phpspy": {"libphp"},
+}
+
+// isSpyReady reports whether spyName should be able to attach to pid right
+// now: for spies with a known interpreter marker, by checking /proc/<pid>/maps;
+// otherwise by attempting a no-op ptrace attach/detach, the same operation
+// ptrace-based spies perform for real once profiling starts.
+func isSpyReady(pid int, spyName string) (bool, error) {
+	if markers, ok := interpreterMarkers[spyName]; ok {
+		return mapsContainAny(pid, markers)
+	}
+	return ptraceRoundTrip(pid)
+}
+
+func mapsContainAny(pid int, markers []string) (bool, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/maps", pid))
+	if err != nil {
+		if os.IsNotExist(err) || os.IsPermission(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	maps := string(b)
+	for _, m := range markers {
+		if strings.Contains(maps, m) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ptraceRoundTrip attaches to pid and immediately detaches, the same
+// operation ptrace-based spies perform for real once profiling starts, to
+// check whether the kernel will allow it yet (it won't until the target has
+// finished exec'ing, dropped any privileges it's going to drop, etc.)
+//
+// PtraceAttach/PtraceDetach are thread-affine: the tracer is the specific
+// OS thread that issued PTRACE_ATTACH, so this must run with the calling
+// goroutine pinned to one via runtime.LockOSThread, or the Go scheduler can
+// migrate it before PtraceDetach runs and the detach fails or affects the
+// wrong thread.
+func ptraceRoundTrip(pid int) (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := syscall.PtraceAttach(pid); err != nil {
+		if err == syscall.EPERM || err == syscall.ESRCH {
+			return false, nil
+		}
+		return false, err
+	}
+	// From here on the target is attached; make sure it's detached again
+	// on every return path, or a readiness check that bails out early
+	// would leave it parked in ptrace-stop forever.
+	detached := false
+	defer func() {
+		if !detached {
+			_ = syscall.PtraceDetach(pid)
+		}
+	}()
+
+	// PtraceAttach delivers a SIGSTOP that must be reaped before
+	// PtraceDetach will work, but pid is also cmd's child: a blocking
+	// Wait4 here can instead reap the child's real exit if it happens to
+	// die in this window, stealing that status out from under the eventual
+	// cmd.Wait() (which then fails with "no child processes"). Poll with
+	// WNOHANG instead, so we only ever consume a status that's already
+	// there, giving up without blocking further if the stop doesn't show
+	// up within readinessPtraceWaitTimeout.
+	deadline := time.Now().Add(readinessPtraceWaitTimeout)
+	for {
+		var ws syscall.WaitStatus
+		waitedPid, err := syscall.Wait4(pid, &ws, syscall.WUNTRACED|syscall.WNOHANG, nil)
+		switch {
+		case err != nil:
+			return false, nil
+		case waitedPid == pid && ws.Stopped():
+			if derr := syscall.PtraceDetach(pid); derr != nil {
+				return false, derr
+			}
+			detached = true
+			return true, nil
+		case waitedPid == pid:
+			// The child changed state, but not into the ptrace-stop we
+			// were waiting for — most likely it exited. Either way,
+			// there's nothing left to detach from.
+			return false, nil
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(readinessPtracePollInterval)
+	}
+}
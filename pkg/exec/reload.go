@@ -0,0 +1,142 @@
+package exec
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/pyroscope-io/pyroscope/pkg/agent"
+	"github.com/pyroscope-io/pyroscope/pkg/agent/upstream"
+	"github.com/pyroscope-io/pyroscope/pkg/agent/upstream/remote"
+	"github.com/pyroscope-io/pyroscope/pkg/config"
+	"github.com/sirupsen/logrus"
+)
+
+// sessionHolder lets the SIGHUP handler swap the live *agent.Session out
+// from under spawnAndRun when a change can't be applied to a running
+// session in place (currently: the sample rate, which a spy is only told
+// once, at attach time).
+type sessionHolder struct {
+	mu   sync.Mutex
+	sess *agent.Session
+}
+
+func newSessionHolder(sess *agent.Session) *sessionHolder {
+	return &sessionHolder{sess: sess}
+}
+
+func (h *sessionHolder) current() *agent.Session {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sess
+}
+
+// replace installs sess as current and returns the one it displaced.
+func (h *sessionHolder) replace(sess *agent.Session) *agent.Session {
+	h.mu.Lock()
+	old := h.sess
+	h.sess = sess
+	h.mu.Unlock()
+	return old
+}
+
+// watchForReload installs a SIGHUP handler that re-reads the on-disk
+// configuration and applies whatever changed without touching the profiled
+// child, so users running `pyroscope exec` under systemd/supervisord can
+// adjust it without losing their running workload:
+//   - upstream-relevant fields (server address, auth token, ...) rebuild the
+//     remote upstream and hot-swap it into the live session;
+//   - a changed sample rate stops the current session and starts a fresh
+//     one against the same pid, since a spy is only told its sample rate
+//     once, at attach time;
+//   - anything else (currently just the application name) is updated on the
+//     live session in place.
+//
+// It returns a function that stops watching.
+func watchForReload(cfg *config.Config, pid int, spyName string, holder *sessionHolder, tracker *upstreamTracker) func() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-sighup:
+				reloadConfig(cfg, pid, spyName, holder, tracker)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sighup)
+		close(done)
+	}
+}
+
+// reloadConfig re-reads the configuration and applies whatever changed to
+// holder's current session. cfg is updated in place so the next SIGHUP
+// diffs against what's actually live rather than the process's original
+// flags.
+func reloadConfig(cfg *config.Config, pid int, spyName string, holder *sessionHolder, tracker *upstreamTracker) {
+	newCfg, err := config.Load()
+	if err != nil {
+		logrus.Errorf("reloading config on SIGHUP: %v", err)
+		return
+	}
+
+	changed := false
+	if upstreamConfigChanged(cfg.Exec, newCfg.Exec) {
+		u := remote.New(remote.RemoteConfig{
+			UpstreamAddress:        newCfg.Exec.ServerAddress,
+			UpstreamThreads:        newCfg.Exec.UpstreamThreads,
+			UpstreamRequestTimeout: newCfg.Exec.UpstreamRequestTimeout,
+			AuthToken:              newCfg.Exec.AuthToken,
+		})
+		// tracker is updated first, so that a later restart respawn, the
+		// final shutdown, or anything resolving through it (e.g. the metrics
+		// exporter) moves to u before the old upstream is stopped.
+		// SetUpstream then drains the old upstream's queue and stops it.
+		tracker.set(u)
+		holder.current().SetUpstream(u)
+		logrus.Info("reloaded configuration on SIGHUP: upstream changed")
+		changed = true
+	}
+
+	if cfg.Exec.SampleRate != newCfg.Exec.SampleRate {
+		// The fresh session is created with the new application name too.
+		old := holder.current()
+		fresh := agent.NewSession(currentUpstreamOf(old), newCfg.Exec.ApplicationName, spyName, newCfg.Exec.SampleRate, pid, newCfg.Exec.DetectSubprocesses)
+		fresh.Start()
+		holder.replace(fresh)
+		old.Stop()
+		logrus.Info("reloaded configuration on SIGHUP: sample rate changed, session restarted")
+		changed = true
+	} else if cfg.Exec.ApplicationName != newCfg.Exec.ApplicationName {
+		holder.current().SetAppName(newCfg.Exec.ApplicationName)
+		logrus.Info("reloaded configuration on SIGHUP: application name changed")
+		changed = true
+	}
+
+	if !changed {
+		logrus.Debug("received SIGHUP, no reloadable config changed")
+	}
+
+	*cfg = *newCfg
+}
+
+func upstreamConfigChanged(a, b config.Exec) bool {
+	return a.ServerAddress != b.ServerAddress ||
+		a.UpstreamThreads != b.UpstreamThreads ||
+		a.UpstreamRequestTimeout != b.UpstreamRequestTimeout ||
+		a.AuthToken != b.AuthToken
+}
+
+// currentUpstreamOf lets the sample-rate-changed path carry the existing
+// upstream over to the replacement session rather than dropping samples by
+// reconnecting.
+func currentUpstreamOf(sess *agent.Session) upstream.Upstream {
+	return sess.Upstream()
+}
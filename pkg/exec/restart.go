@@ -0,0 +1,89 @@
+package exec
+
+import (
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// RestartPolicy controls whether exec.Cli respawns the child after it
+// exits, mirroring the restart-policy semantics Docker/Kubernetes users are
+// already familiar with.
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "no"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartAlways    RestartPolicy = "always"
+)
+
+// exitOutcome describes how a profiled child terminated, independent of
+// whatever error handling the caller layers on top (re-raising a signal,
+// wrapping a non-zero code as *ExitError, and so on).
+type exitOutcome struct {
+	exitCode int
+	signal   syscall.Signal
+	signaled bool
+}
+
+// failed reports whether the child's termination should count as a failure
+// for the purposes of a -restart=on-failure policy.
+func (o exitOutcome) failed() bool {
+	return o.signaled || o.exitCode != 0
+}
+
+func (o exitOutcome) String() string {
+	if o.signaled {
+		return fmt.Sprintf("signal: %s", o.signal)
+	}
+	return fmt.Sprintf("exit status %d", o.exitCode)
+}
+
+// shouldRestart reports whether the supervisor should spawn a fresh attempt
+// given how the previous one exited.
+func shouldRestart(policy RestartPolicy, outcome exitOutcome) bool {
+	switch policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return outcome.failed()
+	default:
+		return false
+	}
+}
+
+// nextBackoff doubles d, capped at max. A non-positive max disables the cap.
+func nextBackoff(d, max time.Duration) time.Duration {
+	d *= 2
+	if max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+// maxRetriesReached reports whether retries (the number of restarts already
+// performed, not counting the initial spawn) has used up
+// -restart-max-retries. A non-positive max means unlimited, matching
+// RestartBackoffMax's convention.
+func maxRetriesReached(retries, max int) bool {
+	return max > 0 && retries >= max
+}
+
+// shuttingDown is set once pyroscope itself has been asked to terminate
+// (currently: by one of the signals forwardSignals treats as terminating),
+// so the restart loop in cli() can stop spawning new attempts instead of
+// fighting a shutdown already in progress — without it, a -restart=always
+// policy would keep respawning the child against a wrapper that's already
+// on its way out.
+var shuttingDown int32
+
+// requestShutdown records that pyroscope should not restart the child again.
+func requestShutdown() {
+	atomic.StoreInt32(&shuttingDown, 1)
+}
+
+// isShuttingDown reports whether requestShutdown has been called.
+func isShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) == 1
+}
@@ -0,0 +1,45 @@
+package exec
+
+import (
+	"os"
+	"time"
+
+	"github.com/pyroscope-io/pyroscope/pkg/agent/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// resourceUsage summarizes cmd.ProcessState.SysUsage() in OS-independent
+// units, for the structured log line and metrics push below.
+type resourceUsage struct {
+	UserCPU     time.Duration
+	SysCPU      time.Duration
+	MaxRSSBytes uint64
+	MinFaults   uint64
+	MajFaults   uint64
+}
+
+// reportResourceUsage logs a structured summary of how much CPU/memory/page
+// faults the just-exited child consumed, and pushes the same numbers through
+// m so a configured backend can correlate "this profile covers a run that
+// used 42s CPU / 180MB RSS / 12k major faults" with the samples collected
+// during the same window.
+func reportResourceUsage(state *os.ProcessState, m metrics.Exporter) {
+	if state == nil {
+		return
+	}
+	usage, ok := rusageFrom(state)
+	if !ok {
+		return
+	}
+
+	logrus.Infof(
+		"child process resource usage: %s user, %s sys CPU, %d MB max RSS, %d minor / %d major page faults",
+		usage.UserCPU, usage.SysCPU, usage.MaxRSSBytes/(1<<20), usage.MinFaults, usage.MajFaults,
+	)
+
+	m.Counter("exec_child_cpu_user_seconds", usage.UserCPU.Seconds(), nil)
+	m.Counter("exec_child_cpu_sys_seconds", usage.SysCPU.Seconds(), nil)
+	m.Gauge("exec_child_max_rss_bytes", float64(usage.MaxRSSBytes), nil)
+	m.Counter("exec_child_minor_page_faults", float64(usage.MinFaults), nil)
+	m.Counter("exec_child_major_page_faults", float64(usage.MajFaults), nil)
+}
@@ -0,0 +1,23 @@
+package exec
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// rusageFrom extracts resource usage from state. On Darwin, unlike Linux,
+// Maxrss is already reported in bytes.
+func rusageFrom(state *os.ProcessState) (resourceUsage, bool) {
+	ru, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return resourceUsage{}, false
+	}
+	return resourceUsage{
+		UserCPU:     time.Duration(ru.Utime.Nano()),
+		SysCPU:      time.Duration(ru.Stime.Nano()),
+		MaxRSSBytes: uint64(ru.Maxrss),
+		MinFaults:   uint64(ru.Minflt),
+		MajFaults:   uint64(ru.Majflt),
+	}, true
+}
@@ -0,0 +1,23 @@
+package exec
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// rusageFrom extracts resource usage from state. On Linux, Maxrss is
+// reported in kilobytes.
+func rusageFrom(state *os.ProcessState) (resourceUsage, bool) {
+	ru, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return resourceUsage{}, false
+	}
+	return resourceUsage{
+		UserCPU:     time.Duration(ru.Utime.Nano()),
+		SysCPU:      time.Duration(ru.Stime.Nano()),
+		MaxRSSBytes: uint64(ru.Maxrss) * 1024,
+		MinFaults:   uint64(ru.Minflt),
+		MajFaults:   uint64(ru.Majflt),
+	}, true
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package exec
+
+import "os"
+
+// rusageFrom is unsupported on platforms we don't have a syscall.Rusage
+// layout for.
+func rusageFrom(*os.ProcessState) (resourceUsage, bool) {
+	return resourceUsage{}, false
+}
@@ -0,0 +1,45 @@
+package exec
+
+import (
+	"sync"
+
+	"github.com/pyroscope-io/pyroscope/pkg/agent/upstream"
+)
+
+// upstreamTracker is the single source of truth for "the upstream currently
+// in use", shared between cli()'s restart loop, its final cleanup and the
+// SIGHUP reload handler. Without it, each of those ends up holding its own
+// stale reference: a restart loop that always respawns against the
+// upstream built at startup sends post-reload data to the wrong server, and
+// final cleanup stops an upstream instance nothing is using any more
+// instead of the live one.
+type upstreamTracker struct {
+	mu       sync.Mutex
+	u        upstream.Upstream
+	stopOnce sync.Once
+}
+
+func newUpstreamTracker(u upstream.Upstream) *upstreamTracker {
+	return &upstreamTracker{u: u}
+}
+
+func (t *upstreamTracker) get() upstream.Upstream {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.u
+}
+
+// set records u as current, e.g. right after a SIGHUP-triggered
+// Session.SetUpstream swap.
+func (t *upstreamTracker) set(u upstream.Upstream) {
+	t.mu.Lock()
+	t.u = u
+	t.mu.Unlock()
+}
+
+// stop stops whichever upstream is current, exactly once, no matter whether
+// it's called from a fatal-signal cleanup path or from cli()'s own deferred
+// shutdown.
+func (t *upstreamTracker) stop() {
+	t.stopOnce.Do(func() { t.get().Stop() })
+}